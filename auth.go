@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	echo "github.com/labstack/echo/v4"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/drive/v3"
+)
+
+const sessionCookieName = "gdrive_session"
+
+// ctxKey namespaces values this package stores on a context.Context.
+type ctxKey string
+
+const userIDContextKey ctxKey = "user_id"
+
+// withUserID attaches an authenticated user ID to ctx so GDriveStorage can
+// pick a user-OAuth client instead of the service account.
+func withUserID(ctx context.Context, userId string) context.Context {
+	return context.WithValue(ctx, userIDContextKey, userId)
+}
+
+// userIDFromContext returns the user ID previously attached by withUserID.
+func userIDFromContext(ctx context.Context) (string, bool) {
+	userId, ok := ctx.Value(userIDContextKey).(string)
+	return userId, ok && userId != ""
+}
+
+// oauthConfig builds the three-legged OAuth config from environment
+// variables. Mirrors the service-account setup in ServiceAccount, but for
+// a real Google user account rather than a single credentials file.
+func oauthConfig() *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     os.Getenv("GOOGLE_OAUTH_CLIENT_ID"),
+		ClientSecret: os.Getenv("GOOGLE_OAUTH_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("GOOGLE_OAUTH_REDIRECT_URL"),
+		Scopes:       []string{drive.DriveScope},
+		Endpoint:     google.Endpoint,
+	}
+}
+
+// TokenStore persists OAuth tokens per user ID so the server can act on
+// behalf of many Google accounts at once.
+type TokenStore interface {
+	Save(ctx context.Context, userId string, token *oauth2.Token) error
+	Load(ctx context.Context, userId string) (*oauth2.Token, error)
+}
+
+// InMemoryTokenStore is the default TokenStore, backed by a guarded map.
+type InMemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*oauth2.Token
+}
+
+// NewInMemoryTokenStore creates an empty in-memory token store.
+func NewInMemoryTokenStore() *InMemoryTokenStore {
+	return &InMemoryTokenStore{tokens: make(map[string]*oauth2.Token)}
+}
+
+func (s *InMemoryTokenStore) Save(ctx context.Context, userId string, token *oauth2.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[userId] = token
+	return nil
+}
+
+func (s *InMemoryTokenStore) Load(ctx context.Context, userId string) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[userId]
+	if !ok {
+		return nil, fmt.Errorf("no token stored for user %q", userId)
+	}
+	return token, nil
+}
+
+// FileTokenStore persists tokens as a JSON file, keyed by user ID.
+type FileTokenStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileTokenStore creates a TokenStore backed by a JSON file at path.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+func (s *FileTokenStore) read() (map[string]*oauth2.Token, error) {
+	tokens := make(map[string]*oauth2.Token)
+	b, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return tokens, nil
+	}
+	if err := json.Unmarshal(b, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (s *FileTokenStore) Save(ctx context.Context, userId string, token *oauth2.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+	tokens[userId] = token
+
+	b, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, b, 0o600)
+}
+
+func (s *FileTokenStore) Load(ctx context.Context, userId string) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	token, ok := tokens[userId]
+	if !ok {
+		return nil, fmt.Errorf("no token stored for user %q", userId)
+	}
+	return token, nil
+}
+
+// tokenStore is the process-wide store for user-OAuth tokens.
+var tokenStore TokenStore = NewInMemoryTokenStore()
+
+// devInsecureSessions reports whether the operator has explicitly opted
+// into running without a real SESSION_SECRET (and without Secure cookies),
+// e.g. for local development over plain HTTP.
+func devInsecureSessions() bool {
+	ok, _ := strconv.ParseBool(os.Getenv("SESSION_SECRET_DEV_INSECURE"))
+	return ok
+}
+
+// sessionSecret returns the HMAC key used to sign session cookies. This key
+// is the only thing standing between a request and another tenant's linked
+// Drive account (see GDriveStorage.client/tokenStore.Load), so it fails
+// closed: a missing SESSION_SECRET is fatal unless the operator explicitly
+// opts into SESSION_SECRET_DEV_INSECURE for local development.
+func sessionSecret() []byte {
+	if secret := os.Getenv("SESSION_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	if devInsecureSessions() {
+		return []byte("dev-only-insecure-session-secret")
+	}
+	log.Fatal("SESSION_SECRET is required (set SESSION_SECRET_DEV_INSECURE=true to run locally without one)")
+	return nil
+}
+
+func signUserID(userId string) string {
+	mac := hmac.New(sha256.New, sessionSecret())
+	mac.Write([]byte(userId))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func newSessionCookie(userId string) *http.Cookie {
+	value := userId + "." + signUserID(userId)
+	return &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   !devInsecureSessions(),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// userIDFromRequest verifies the signed session cookie and returns the
+// user ID it authenticates, if any.
+func userIDFromRequest(c echo.Context) (string, bool) {
+	cookie, err := c.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	parts := strings.SplitN(cookie.Value, ".", 2)
+	if len(parts) != 2 {
+		return "", false
+	}
+	userId, signature := parts[0], parts[1]
+	if !hmac.Equal([]byte(signature), []byte(signUserID(userId))) {
+		return "", false
+	}
+	return userId, true
+}
+
+// linkedUserIDFromRequest verifies the signed session cookie AND that the
+// user has actually completed the OAuth link (tokenStore holds a token for
+// them). userIDFromRequest alone only proves the cookie was minted by this
+// server, which happens in googleLoginHandler before consent is granted —
+// use this wherever a request needs to prove a completed login rather than
+// just a validly-signed, possibly-unlinked session cookie.
+func linkedUserIDFromRequest(c echo.Context) (string, bool) {
+	userId, ok := userIDFromRequest(c)
+	if !ok {
+		return "", false
+	}
+	if _, err := tokenStore.Load(c.Request().Context(), userId); err != nil {
+		return "", false
+	}
+	return userId, true
+}
+
+func newUserID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// googleLoginHandler redirects the browser to Google's OAuth consent screen.
+// The user ID is minted up front, carried through the OAuth "state"
+// parameter, and set as a signed cookie so the callback can tie the
+// exchanged token back to this session.
+func googleLoginHandler(c echo.Context) error {
+	userId, err := newUserID()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to start login"})
+	}
+
+	c.SetCookie(newSessionCookie(userId))
+
+	url := oauthConfig().AuthCodeURL(userId, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
+	return c.Redirect(http.StatusFound, url)
+}
+
+// googleCallbackHandler exchanges the OAuth code for a token and stores it
+// against the user ID carried in the "state" parameter.
+func googleCallbackHandler(c echo.Context) error {
+	state := c.QueryParam("state")
+	cookieUserId, ok := userIDFromRequest(c)
+	if !ok || state == "" || cookieUserId != state {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid or expired login session"})
+	}
+
+	code := c.QueryParam("code")
+	if code == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Missing authorization code"})
+	}
+
+	token, err := oauthConfig().Exchange(c.Request().Context(), code)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to exchange authorization code"})
+	}
+
+	if err := tokenStore.Save(c.Request().Context(), state, token); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to persist user token"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Google account linked successfully"})
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	echo "github.com/labstack/echo/v4"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+const defaultChangesPollInterval = 30 * time.Second
+
+// ChangeEvent is the fan-out payload emitted for every Drive change.
+type ChangeEvent struct {
+	FileId       string   `json:"fileId"`
+	Name         string   `json:"name"`
+	Parents      []string `json:"parents"`
+	MimeType     string   `json:"mimeType"`
+	ChangeType   string   `json:"changeType"`
+	ModifiedTime string   `json:"modifiedTime"`
+}
+
+// Webhook is an HTTP callback registered to receive change events. UserID
+// identifies the authenticated caller that registered it; it's set by
+// registerWebhookHandler, never bound from the request body.
+type Webhook struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+	UserID string `json:"-"`
+}
+
+// ChangesWatcher polls the Drive changes feed and fans events out to SSE
+// subscribers and registered webhooks. It mirrors cloudmount's gdrivefs
+// Service in persisting its start page token so a restart doesn't replay
+// history.
+type ChangesWatcher struct {
+	credentialsFile string
+	tokenFile       string
+	interval        time.Duration
+	folderId        string
+
+	mu          sync.Mutex
+	parentCache map[string][]string
+	fileStates  map[string]fileState
+
+	subMu       sync.Mutex
+	subscribers map[chan ChangeEvent]string
+
+	webhookMu sync.Mutex
+	webhooks  []Webhook
+}
+
+// NewChangesWatcher creates a watcher that persists its page token to
+// tokenFile and polls every interval.
+func NewChangesWatcher(credentialsFile, tokenFile string, interval time.Duration) *ChangesWatcher {
+	return &ChangesWatcher{
+		credentialsFile: credentialsFile,
+		tokenFile:       tokenFile,
+		interval:        interval,
+		parentCache:     make(map[string][]string),
+		fileStates:      make(map[string]fileState),
+		subscribers:     make(map[chan ChangeEvent]string),
+	}
+}
+
+// fileState is the last-seen name/trashed status for a file, kept so toEvent
+// can tell create/rename/untrash apart from a generic update.
+type fileState struct {
+	name    string
+	trashed bool
+}
+
+// WithFolder scopes the watcher to changes underneath a single folder ID.
+func (w *ChangesWatcher) WithFolder(folderId string) *ChangesWatcher {
+	w.folderId = folderId
+	return w
+}
+
+func (w *ChangesWatcher) service(ctx context.Context) (*drive.Service, error) {
+	client := ServiceAccount(w.credentialsFile)
+	return drive.NewService(ctx, option.WithHTTPClient(client))
+}
+
+func (w *ChangesWatcher) loadStartPageToken(srv *drive.Service) (string, error) {
+	if b, err := os.ReadFile(w.tokenFile); err == nil && len(b) > 0 {
+		return string(b), nil
+	}
+
+	token, err := srv.Changes.GetStartPageToken().Do()
+	if err != nil {
+		return "", fmt.Errorf("failed to get start page token: %w", err)
+	}
+	return token.StartPageToken, nil
+}
+
+func (w *ChangesWatcher) saveStartPageToken(token string) {
+	if err := os.WriteFile(w.tokenFile, []byte(token), 0o644); err != nil {
+		log.Printf("failed to persist changes page token: %v\n", err)
+	}
+}
+
+// Run polls the changes feed until ctx is canceled. It's meant to be run in
+// its own goroutine.
+func (w *ChangesWatcher) Run(ctx context.Context) {
+	srv, err := w.service(ctx)
+	if err != nil {
+		log.Printf("changes watcher: %v\n", err)
+		return
+	}
+
+	pageToken, err := w.loadStartPageToken(srv)
+	if err != nil {
+		log.Printf("changes watcher: %v\n", err)
+		return
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pageToken = w.poll(ctx, srv, pageToken)
+		}
+	}
+}
+
+func (w *ChangesWatcher) poll(ctx context.Context, srv *drive.Service, pageToken string) string {
+	for pageToken != "" {
+		call := srv.Changes.List(pageToken).
+			Fields("nextPageToken, newStartPageToken, changes(fileId, removed, time, file(id, name, parents, mimeType, trashed, modifiedTime))")
+		res, err := call.Do()
+		if err != nil {
+			log.Printf("changes watcher: failed to list changes: %v\n", err)
+			return pageToken
+		}
+
+		for _, change := range res.Changes {
+			event := w.toEvent(change)
+			if w.inScope(ctx, srv, event) {
+				w.publish(event)
+			}
+		}
+
+		if res.NewStartPageToken != "" {
+			w.saveStartPageToken(res.NewStartPageToken)
+			return res.NewStartPageToken
+		}
+		pageToken = res.NextPageToken
+	}
+	return pageToken
+}
+
+// toEvent classifies a Drive change into one of create/update/trash/
+// untrash/rename by comparing it against the last-seen fileState for that
+// file ID: a file never seen before is a create (or a trash if it is
+// already trashed), a removed change or a trashed flag flipping either way
+// is trash/untrash, and a changed name (with trashed unchanged) is a
+// rename. Everything else, including a change with no file, falls back to
+// a generic update.
+func (w *ChangesWatcher) toEvent(change *drive.Change) ChangeEvent {
+	event := ChangeEvent{FileId: change.FileId}
+
+	if change.Removed {
+		event.ChangeType = "trash"
+		w.forgetFileState(change.FileId)
+		return event
+	}
+
+	if change.File == nil {
+		event.ChangeType = "update"
+		return event
+	}
+
+	event.Name = change.File.Name
+	event.Parents = change.File.Parents
+	event.MimeType = change.File.MimeType
+	event.ModifiedTime = change.File.ModifiedTime
+
+	prev, seen := w.cachedFileState(change.FileId)
+	switch {
+	case !seen && change.File.Trashed:
+		event.ChangeType = "trash"
+	case !seen:
+		event.ChangeType = "create"
+	case change.File.Trashed && !prev.trashed:
+		event.ChangeType = "trash"
+	case !change.File.Trashed && prev.trashed:
+		event.ChangeType = "untrash"
+	case change.File.Name != prev.name:
+		event.ChangeType = "rename"
+	default:
+		event.ChangeType = "update"
+	}
+
+	w.mu.Lock()
+	w.parentCache[change.FileId] = change.File.Parents
+	w.fileStates[change.FileId] = fileState{name: change.File.Name, trashed: change.File.Trashed}
+	w.mu.Unlock()
+
+	return event
+}
+
+func (w *ChangesWatcher) cachedFileState(fileId string) (fileState, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	state, ok := w.fileStates[fileId]
+	return state, ok
+}
+
+func (w *ChangesWatcher) forgetFileState(fileId string) {
+	w.mu.Lock()
+	delete(w.fileStates, fileId)
+	w.mu.Unlock()
+}
+
+// inScope reports whether event falls under the watched folder, walking the
+// parent chain (lazily populated via Files.Get) when the watcher is scoped.
+func (w *ChangesWatcher) inScope(ctx context.Context, srv *drive.Service, event ChangeEvent) bool {
+	if w.folderId == "" {
+		return true
+	}
+
+	visited := make(map[string]bool)
+	queue := append([]string{}, event.Parents...)
+
+	for len(queue) > 0 {
+		parentId := queue[0]
+		queue = queue[1:]
+
+		if parentId == w.folderId {
+			return true
+		}
+		if visited[parentId] {
+			continue
+		}
+		visited[parentId] = true
+
+		grandparents, ok := w.cachedParents(parentId)
+		if !ok {
+			file, err := srv.Files.Get(parentId).Fields("parents").Do()
+			if err != nil {
+				continue
+			}
+			grandparents = file.Parents
+			w.mu.Lock()
+			w.parentCache[parentId] = grandparents
+			w.mu.Unlock()
+		}
+		queue = append(queue, grandparents...)
+	}
+
+	return false
+}
+
+func (w *ChangesWatcher) cachedParents(fileId string) ([]string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	parents, ok := w.parentCache[fileId]
+	return parents, ok
+}
+
+func (w *ChangesWatcher) publish(event ChangeEvent) {
+	w.subMu.Lock()
+	for ch := range w.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	w.subMu.Unlock()
+
+	w.webhookMu.Lock()
+	webhooks := append([]Webhook{}, w.webhooks...)
+	w.webhookMu.Unlock()
+
+	for _, hook := range webhooks {
+		go deliverWebhook(hook, event)
+	}
+}
+
+// subscribe registers a new SSE listener, tagged with the authenticated
+// userId that opened it (see eventsStreamHandler).
+func (w *ChangesWatcher) subscribe(userId string) chan ChangeEvent {
+	ch := make(chan ChangeEvent, 16)
+	w.subMu.Lock()
+	w.subscribers[ch] = userId
+	w.subMu.Unlock()
+	return ch
+}
+
+func (w *ChangesWatcher) unsubscribe(ch chan ChangeEvent) {
+	w.subMu.Lock()
+	delete(w.subscribers, ch)
+	w.subMu.Unlock()
+	close(ch)
+}
+
+func (w *ChangesWatcher) registerWebhook(hook Webhook) {
+	w.webhookMu.Lock()
+	w.webhooks = append(w.webhooks, hook)
+	w.webhookMu.Unlock()
+}
+
+// deliverWebhook POSTs the event to hook.URL, signing the body with HMAC
+// when a secret is configured, and retries with exponential backoff.
+func deliverWebhook(hook Webhook, event ChangeEvent) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("webhook delivery: failed to encode event: %v\n", err)
+		return
+	}
+
+	backoff := time.Second
+	for attempt := 0; attempt < 5; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+			if hook.Secret != "" {
+				req.Header.Set("X-Signature", signPayload(hook.Secret, body))
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err == nil {
+				resp.Body.Close()
+				if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+					return
+				}
+			}
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	log.Printf("webhook delivery: giving up on %s after retries\n", hook.URL)
+}
+
+// isPublicWebhookURL reports whether rawURL is safe to register as a
+// webhook target: http(s) only, with a host that resolves exclusively to
+// public, routable addresses. Without this, an authenticated caller could
+// still point the server's retrying POSTs at an internal-only service
+// (loopback, link-local/cloud-metadata, or other private-range host).
+func isPublicWebhookURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := u.Hostname()
+	if host == "" {
+		return false
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsPrivate() &&
+		!ip.IsUnspecified() &&
+		!ip.IsMulticast()
+}
+
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// changesWatcher is the process-wide watcher started from main, nil when
+// change watching isn't configured.
+var changesWatcher *ChangesWatcher
+
+// eventsStreamHandler streams change events as Server-Sent Events. It
+// requires a completed OAuth link (see linkedUserIDFromRequest), not merely
+// a validly-signed session cookie: the watcher only ever observes a single
+// Drive feed (the service account's, optionally folder-scoped), so this
+// doesn't partition events per tenant, but it does close the anonymous-
+// access hole that let anyone watch every tenant's activity.
+func eventsStreamHandler(c echo.Context) error {
+	if changesWatcher == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Changes watcher is not enabled"})
+	}
+
+	userId, ok := linkedUserIDFromRequest(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	res := c.Response()
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+
+	ch := changesWatcher.subscribe(userId)
+	defer changesWatcher.unsubscribe(ch)
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case event, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(res, "data: %s\n\n", data); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+// registerWebhookHandler registers an HTTP callback to receive change
+// events. It requires a completed OAuth link (see linkedUserIDFromRequest)
+// so an anonymous caller can't point the server's retrying POSTs at an
+// arbitrary URL (an SSRF vector).
+func registerWebhookHandler(c echo.Context) error {
+	if changesWatcher == nil {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "Changes watcher is not enabled"})
+	}
+
+	userId, ok := linkedUserIDFromRequest(c)
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+	}
+
+	var hook Webhook
+	if err := c.Bind(&hook); err != nil || hook.URL == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "url is required"})
+	}
+	if !isPublicWebhookURL(hook.URL) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "url must be a public http(s) address"})
+	}
+	hook.UserID = userId
+
+	changesWatcher.registerWebhook(hook)
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "Webhook registered"})
+}
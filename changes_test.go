@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+func newTestChangesWatcher() *ChangesWatcher {
+	return NewChangesWatcher("unused-credentials.json", "unused-token-file", time.Second)
+}
+
+func TestToEventClassification(t *testing.T) {
+	w := newTestChangesWatcher()
+
+	// A file never seen before is a create, not a generic update.
+	create := w.toEvent(&drive.Change{
+		FileId: "file-1",
+		File:   &drive.File{Name: "report.pdf", Trashed: false},
+	})
+	if create.ChangeType != "create" {
+		t.Fatalf("first sighting: ChangeType = %q, want %q", create.ChangeType, "create")
+	}
+
+	// Renaming the same file (trashed state unchanged) is a rename.
+	rename := w.toEvent(&drive.Change{
+		FileId: "file-1",
+		File:   &drive.File{Name: "final-report.pdf", Trashed: false},
+	})
+	if rename.ChangeType != "rename" {
+		t.Fatalf("rename: ChangeType = %q, want %q", rename.ChangeType, "rename")
+	}
+
+	// A no-op re-poll of the same name/trashed state is a generic update.
+	update := w.toEvent(&drive.Change{
+		FileId: "file-1",
+		File:   &drive.File{Name: "final-report.pdf", Trashed: false},
+	})
+	if update.ChangeType != "update" {
+		t.Fatalf("no-op update: ChangeType = %q, want %q", update.ChangeType, "update")
+	}
+
+	// Trashing a previously-untrashed file is a trash event.
+	trash := w.toEvent(&drive.Change{
+		FileId: "file-1",
+		File:   &drive.File{Name: "final-report.pdf", Trashed: true},
+	})
+	if trash.ChangeType != "trash" {
+		t.Fatalf("trash: ChangeType = %q, want %q", trash.ChangeType, "trash")
+	}
+
+	// Untrashing a previously-trashed file is an untrash event.
+	untrash := w.toEvent(&drive.Change{
+		FileId: "file-1",
+		File:   &drive.File{Name: "final-report.pdf", Trashed: false},
+	})
+	if untrash.ChangeType != "untrash" {
+		t.Fatalf("untrash: ChangeType = %q, want %q", untrash.ChangeType, "untrash")
+	}
+}
+
+func TestToEventFirstSightingTrashedIsTrash(t *testing.T) {
+	w := newTestChangesWatcher()
+
+	event := w.toEvent(&drive.Change{
+		FileId: "file-2",
+		File:   &drive.File{Name: "already-trashed.pdf", Trashed: true},
+	})
+	if event.ChangeType != "trash" {
+		t.Errorf("first sighting already trashed: ChangeType = %q, want %q", event.ChangeType, "trash")
+	}
+}
+
+func TestToEventRemovedIsTrashAndForgetsState(t *testing.T) {
+	w := newTestChangesWatcher()
+
+	w.toEvent(&drive.Change{
+		FileId: "file-3",
+		File:   &drive.File{Name: "doomed.pdf", Trashed: false},
+	})
+
+	removed := w.toEvent(&drive.Change{FileId: "file-3", Removed: true})
+	if removed.ChangeType != "trash" {
+		t.Fatalf("removed change: ChangeType = %q, want %q", removed.ChangeType, "trash")
+	}
+
+	if _, seen := w.cachedFileState("file-3"); seen {
+		t.Error("removed change should forget the file's cached state")
+	}
+
+	// With state forgotten, seeing the ID again is treated as a fresh create.
+	recreated := w.toEvent(&drive.Change{
+		FileId: "file-3",
+		File:   &drive.File{Name: "doomed.pdf", Trashed: false},
+	})
+	if recreated.ChangeType != "create" {
+		t.Errorf("re-created file: ChangeType = %q, want %q", recreated.ChangeType, "create")
+	}
+}
+
+func TestToEventNilFileIsUpdate(t *testing.T) {
+	w := newTestChangesWatcher()
+
+	event := w.toEvent(&drive.Change{FileId: "file-4"})
+	if event.ChangeType != "update" {
+		t.Errorf("nil file change: ChangeType = %q, want %q", event.ChangeType, "update")
+	}
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/jwt"
+	"google.golang.org/api/drive/v3"
+	"google.golang.org/api/option"
+)
+
+// driveIDContextKey carries an optional Shared Drive ID on the context so
+// every handler can opt into searching/creating across Shared Drives.
+const driveIDContextKey ctxKey = "drive_id"
+
+// withDriveID attaches a Shared Drive ID to ctx.
+func withDriveID(ctx context.Context, driveId string) context.Context {
+	return context.WithValue(ctx, driveIDContextKey, driveId)
+}
+
+// driveIDFromContext returns the Shared Drive ID previously attached by
+// withDriveID, if any.
+func driveIDFromContext(ctx context.Context) (string, bool) {
+	driveId, ok := ctx.Value(driveIDContextKey).(string)
+	return driveId, ok && driveId != ""
+}
+
+// ServiceAccount initializes and returns an authenticated HTTP client
+func ServiceAccount(secretFile string) *http.Client {
+	b, err := os.ReadFile(secretFile)
+	if err != nil {
+		log.Fatal("error while reading the credential file", err)
+	}
+	var s = struct {
+		Email      string `json:"client_email"`
+		PrivateKey string `json:"private_key"`
+	}{}
+	json.Unmarshal(b, &s)
+	config := &jwt.Config{
+		Email:      s.Email,
+		PrivateKey: []byte(s.PrivateKey),
+		Scopes: []string{
+			drive.DriveScope,
+		},
+		TokenURL: google.JWTTokenURL,
+	}
+	client := config.Client(context.Background())
+	return client
+}
+
+// createFile uploads a file to Google Drive
+func createFile(service *drive.Service, name string, mimeType string, content io.Reader, parentId string) (*drive.File, error) {
+	f := &drive.File{
+		MimeType: mimeType,
+		Name:     name,
+		Parents:  []string{parentId},
+	}
+	file, err := service.Files.Create(f).Media(content).Do()
+
+	if err != nil {
+		log.Println("Could not create file: " + err.Error())
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// GDriveStorage stores files in Google Drive using a service account, or a
+// linked user's OAuth token when the request carries one (see client). It
+// preserves the "replace file with the same name" behavior of the original
+// uploadFileHandler.
+type GDriveStorage struct {
+	credentialsFile string
+}
+
+// NewGDriveStorage creates a Storage backed by Google Drive, authenticating
+// with the service account JSON at credentialsFile.
+func NewGDriveStorage(credentialsFile string) *GDriveStorage {
+	return &GDriveStorage{credentialsFile: credentialsFile}
+}
+
+// client returns the *http.Client appropriate for the request: a user-OAuth
+// client when ctx carries an authenticated user ID (see withUserID),
+// falling back to the service account otherwise.
+func (g *GDriveStorage) client(ctx context.Context) (*http.Client, error) {
+	if userId, ok := userIDFromContext(ctx); ok {
+		if token, err := tokenStore.Load(ctx, userId); err == nil {
+			return oauthConfig().Client(ctx, token), nil
+		}
+	}
+	return ServiceAccount(g.credentialsFile), nil
+}
+
+// service returns a *drive.Service built from client.
+func (g *GDriveStorage) service(ctx context.Context) (*drive.Service, error) {
+	client, err := g.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return drive.NewService(ctx, option.WithHTTPClient(client))
+}
+
+// scopedFilesList always applies SupportsAllDrives/IncludeItemsFromAllDrives,
+// and adds Corpora/DriveId when ctx carries a Shared Drive ID (see
+// withDriveID), so every listing handler can reach content living in a
+// Shared Drive.
+func scopedFilesList(ctx context.Context, call *drive.FilesListCall) *drive.FilesListCall {
+	call = call.SupportsAllDrives(true).IncludeItemsFromAllDrives(true)
+	if driveId, ok := driveIDFromContext(ctx); ok {
+		call = call.Corpora("drive").DriveId(driveId)
+	}
+	return call
+}
+
+func (g *GDriveStorage) Put(ctx context.Context, folder, name, contentType string, r io.Reader) (FileInfo, error) {
+	srv, err := g.service(ctx)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to create Google Drive service: %w", err)
+	}
+
+	existing, err := scopedFilesList(ctx, srv.Files.List().Q("name='"+escapeDriveQueryValue(name)+"'")).Fields("files(id)").Do()
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to list files in Google Drive: %w", err)
+	}
+	for _, f := range existing.Files {
+		if err := srv.Files.Delete(f.Id).SupportsAllDrives(true).Do(); err != nil {
+			return FileInfo{}, fmt.Errorf("failed to delete existing file from Google Drive: %w", err)
+		}
+	}
+
+	file, err := createFile(srv, name, contentType, r, folder)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	return g.Head(ctx, file.Id)
+}
+
+func (g *GDriveStorage) Get(ctx context.Context, id string) (io.ReadCloser, string, int64, error) {
+	srv, err := g.service(ctx)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to create Google Drive service: %w", err)
+	}
+
+	resp, err := srv.Files.Get(id).SupportsAllDrives(true).Download()
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to get file from Google Drive: %w", err)
+	}
+
+	return resp.Body, resp.Header.Get("Content-Type"), resp.ContentLength, nil
+}
+
+func (g *GDriveStorage) Head(ctx context.Context, id string) (FileInfo, error) {
+	srv, err := g.service(ctx)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to create Google Drive service: %w", err)
+	}
+
+	file, err := srv.Files.Get(id).SupportsAllDrives(true).Fields("id, name, size, mimeType, modifiedTime, webViewLink").Do()
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to get file metadata from Google Drive: %w", err)
+	}
+
+	return driveFileToInfo(file), nil
+}
+
+func (g *GDriveStorage) HeadByName(ctx context.Context, name string) (FileInfo, error) {
+	srv, err := g.service(ctx)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to create Google Drive service: %w", err)
+	}
+
+	r, err := scopedFilesList(ctx, srv.Files.List().Q("name='"+escapeDriveQueryValue(name)+"'")).Fields("files(id, name, size, mimeType, modifiedTime, webViewLink)").Do()
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to list files in Google Drive: %w", err)
+	}
+	if len(r.Files) == 0 {
+		return FileInfo{}, fmt.Errorf("file not found")
+	}
+
+	return driveFileToInfo(r.Files[0]), nil
+}
+
+// buildListQuery composes a Drive Q() expression from a folder scope plus
+// the /list DSL (mimeType, modifiedAfter, owner, nameContains, trashed),
+// escaping those string values so they can't break out of their quotes.
+// The raw q expression is not escaped: it is ANDed in verbatim, wrapped in
+// parentheses.
+func buildListQuery(folder string, opts ListOptions) string {
+	clauses := make([]string, 0, 6)
+
+	if folder != "" {
+		clauses = append(clauses, "'"+escapeDriveQueryValue(folder)+"' in parents")
+	}
+	if opts.MimeType != "" {
+		clauses = append(clauses, "mimeType='"+escapeDriveQueryValue(opts.MimeType)+"'")
+	}
+	if opts.ModifiedAfter != "" {
+		clauses = append(clauses, "modifiedTime > '"+escapeDriveQueryValue(opts.ModifiedAfter)+"'")
+	}
+	if opts.Owner != "" {
+		clauses = append(clauses, "'"+escapeDriveQueryValue(opts.Owner)+"' in owners")
+	}
+	if opts.NameContains != "" {
+		clauses = append(clauses, "name contains '"+escapeDriveQueryValue(opts.NameContains)+"'")
+	}
+	if opts.Trashed != nil {
+		clauses = append(clauses, fmt.Sprintf("trashed=%t", *opts.Trashed))
+	}
+	if opts.Q != "" {
+		clauses = append(clauses, "("+opts.Q+")")
+	}
+
+	return strings.Join(clauses, " and ")
+}
+
+func (g *GDriveStorage) List(ctx context.Context, folder string, opts ListOptions) ([]FileInfo, string, error) {
+	srv, err := g.service(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create Google Drive service: %w", err)
+	}
+
+	call := srv.Files.List().Fields("nextPageToken, files(id, name, size, mimeType, modifiedTime, webViewLink)")
+	if q := buildListQuery(folder, opts); q != "" {
+		call = call.Q(q)
+	}
+	if opts.OrderBy != "" {
+		call = call.OrderBy(opts.OrderBy)
+	}
+	if opts.PageSize > 0 {
+		call = call.PageSize(opts.PageSize)
+	}
+	if opts.PageToken != "" {
+		call = call.PageToken(opts.PageToken)
+	}
+	call = scopedFilesList(ctx, call)
+
+	r, err := call.Do()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list files in Google Drive: %w", err)
+	}
+
+	files := make([]FileInfo, 0, len(r.Files))
+	for _, f := range r.Files {
+		files = append(files, driveFileToInfo(f))
+	}
+	return files, r.NextPageToken, nil
+}
+
+func (g *GDriveStorage) Delete(ctx context.Context, id string) error {
+	srv, err := g.service(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create Google Drive service: %w", err)
+	}
+
+	if err := srv.Files.Delete(id).SupportsAllDrives(true).Do(); err != nil {
+		return fmt.Errorf("failed to delete file from Google Drive: %w", err)
+	}
+	return nil
+}
+
+func driveFileToInfo(f *drive.File) FileInfo {
+	return FileInfo{
+		ID:          f.Id,
+		Name:        f.Name,
+		Size:        f.Size,
+		ContentType: f.MimeType,
+		WebViewLink: f.WebViewLink,
+	}
+}
+
+// escapeDriveQueryValue escapes backslashes and single quotes, in that
+// order, so user-controlled values cannot break out of a Drive Q() string
+// literal. Escaping quotes first would let a trailing backslash in the
+// input swallow the closing quote this function inserts.
+func escapeDriveQueryValue(value string) string {
+	escaped := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' || value[i] == '\'' {
+			escaped = append(escaped, '\\')
+		}
+		escaped = append(escaped, value[i])
+	}
+	return string(escaped)
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestEscapeDriveQueryValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"no special characters", "report.pdf", "report.pdf"},
+		{"single quote", "o'brien.pdf", `o\'brien.pdf`},
+		{"trailing backslash", `foo\`, `foo\\`},
+		{"backslash then quote", `foo\'bar`, `foo\\\'bar`},
+		{"quote then backslash", `foo'\bar`, `foo\'\\bar`},
+		{"multiple backslashes", `a\\b`, `a\\\\b`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeDriveQueryValue(tt.value); got != tt.want {
+				t.Errorf("escapeDriveQueryValue(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEscapeDriveQueryValueCannotEscapeClosingQuote guards against the
+// regression fixed in c033c4b: a value ending in a backslash must not be
+// able to swallow the closing quote a call site wraps the escaped value in.
+func TestEscapeDriveQueryValueCannotEscapeClosingQuote(t *testing.T) {
+	escaped := escapeDriveQueryValue(`foo\`)
+	query := "name contains '" + escaped + "' and trashed=false"
+
+	quoteCount := 0
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			i++ // skip the character this backslash escapes
+		case '\'':
+			quoteCount++
+		}
+	}
+	if quoteCount != 2 {
+		t.Errorf("expected the escaped value to close its own quote, leaving 2 unescaped quotes in %q, got %d", query, quoteCount)
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+// context returns the request context, carrying the authenticated user ID
+// (see withUserID) when the request has a valid session cookie, so
+// GDriveStorage can resolve a user-OAuth client instead of the service
+// account, and the "driveId" query/form parameter (see withDriveID) so it
+// can reach content living in a Shared Drive.
+func (h *Handler) context(c echo.Context) context.Context {
+	ctx := c.Request().Context()
+	if userId, ok := userIDFromRequest(c); ok {
+		ctx = withUserID(ctx, userId)
+	}
+	if driveId := driveIDParam(c); driveId != "" {
+		ctx = withDriveID(ctx, driveId)
+	}
+	return ctx
+}
+
+func driveIDParam(c echo.Context) string {
+	if driveId := c.QueryParam("driveId"); driveId != "" {
+		return driveId
+	}
+	return c.FormValue("driveId")
+}
+
+// UploadFile handles the file upload to the configured storage backend.
+func (h *Handler) UploadFile(c echo.Context) error {
+	folderId := c.FormValue("folder")
+	if folderId == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Folder ID is required"})
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to read file from request"})
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to open file"})
+	}
+	defer src.Close()
+
+	info, err := h.storage.Put(h.context(c), folderId, file.Filename, file.Header.Get("Content-Type"), src)
+	if err != nil {
+		log.Println(err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to upload file"})
+	}
+
+	data := map[string]interface{}{
+		"file_id":   info.ID,
+		"file_name": info.Name,
+		"file_url":  info.WebViewLink,
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "File successfully uploaded",
+		"data":    data,
+	})
+}
+
+// ListFiles handles the listing of files in a storage folder, optionally
+// narrowed by the /list query DSL (q, mimeType, modifiedAfter, owner,
+// nameContains, trashed, orderBy) and paginated via pageSize/pageToken.
+func (h *Handler) ListFiles(c echo.Context) error {
+	folderId := c.QueryParam("folder")
+	if folderId == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Folder ID is required"})
+	}
+
+	opts := ListOptions{
+		Q:             c.QueryParam("q"),
+		MimeType:      c.QueryParam("mimeType"),
+		ModifiedAfter: c.QueryParam("modifiedAfter"),
+		Owner:         c.QueryParam("owner"),
+		NameContains:  c.QueryParam("nameContains"),
+		OrderBy:       c.QueryParam("orderBy"),
+		PageToken:     c.QueryParam("pageToken"),
+	}
+	if raw := c.QueryParam("trashed"); raw != "" {
+		if trashed, err := strconv.ParseBool(raw); err == nil {
+			opts.Trashed = &trashed
+		}
+	}
+	if raw := c.QueryParam("pageSize"); raw != "" {
+		if pageSize, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			opts.PageSize = pageSize
+		}
+	}
+
+	files, nextPageToken, err := h.storage.List(h.context(c), folderId, opts)
+	if err != nil {
+		log.Println(err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list files"})
+	}
+
+	data := map[string]interface{}{
+		"folder_id":       folderId,
+		"files":           files,
+		"next_page_token": nextPageToken,
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Files successfully retrieved",
+		"data":    data,
+	})
+}
+
+// DownloadFile streams a file from the storage backend to the client. When
+// the backend supports it (RangedStorage), Google Workspace files are
+// exported to a downloadable format and Range requests are honored with a
+// 206 Partial Content response.
+func (h *Handler) DownloadFile(c echo.Context) error {
+	fileId := c.Param("fileId")
+	if fileId == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "File ID is required"})
+	}
+
+	ranged, ok := h.storage.(RangedStorage)
+	if !ok {
+		body, contentType, _, err := h.storage.Get(h.context(c), fileId)
+		if err != nil {
+			log.Println(err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get file"})
+		}
+		defer body.Close()
+
+		return c.Stream(http.StatusOK, contentType, body)
+	}
+
+	opts := GetOptions{
+		Range:  c.Request().Header.Get("Range"),
+		Export: c.QueryParam("export"),
+	}
+
+	body, contentType, _, cr, err := ranged.GetWithOptions(h.context(c), fileId, opts)
+	if err != nil {
+		log.Println(err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get file"})
+	}
+	defer body.Close()
+
+	c.Response().Header().Set("Accept-Ranges", "bytes")
+	if cr != nil {
+		c.Response().Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", cr.Start, cr.End, cr.Total))
+		return c.Stream(http.StatusPartialContent, contentType, body)
+	}
+
+	return c.Stream(http.StatusOK, contentType, body)
+}
+
+// Thumbnail streams a file's thumbnail image through the server when the
+// backend supports it (ThumbnailStorage), so the client never needs its own
+// Drive credentials.
+func (h *Handler) Thumbnail(c echo.Context) error {
+	fileId := c.Param("fileId")
+	if fileId == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "File ID is required"})
+	}
+
+	thumbnails, ok := h.storage.(ThumbnailStorage)
+	if !ok {
+		return c.JSON(http.StatusNotImplemented, map[string]string{"error": "This storage backend does not support thumbnails"})
+	}
+
+	body, contentType, err := thumbnails.Thumbnail(h.context(c), fileId, c.QueryParam("size"))
+	if err != nil {
+		log.Println(err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get thumbnail"})
+	}
+	defer body.Close()
+
+	return c.Stream(http.StatusOK, contentType, body)
+}
+
+// GetFileMetadata handles getting metadata of a file by ID, or by name when
+// the backend supports name lookups.
+func (h *Handler) GetFileMetadata(c echo.Context) error {
+	type requestBody struct {
+		FileID   string `json:"file_id"`
+		FileName string `json:"file_name"`
+	}
+
+	var body requestBody
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if body.FileID != "" {
+		info, err := h.storage.Head(h.context(c), body.FileID)
+		if err != nil {
+			log.Println(err)
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to get file metadata"})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": "File metadata successfully retrieved",
+			"data":    metadataResponse(info),
+		})
+	}
+
+	if body.FileName != "" {
+		lookup, ok := h.storage.(NameLookupStorage)
+		if !ok {
+			return c.JSON(http.StatusNotImplemented, map[string]string{"error": "This storage backend does not support lookup by name"})
+		}
+
+		info, err := lookup.HeadByName(h.context(c), body.FileName)
+		if err != nil {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "File not found"})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": "File metadata successfully retrieved",
+			"data":    metadataResponse(info),
+		})
+	}
+
+	return c.JSON(http.StatusBadRequest, map[string]string{"error": "File ID or File Name is required"})
+}
+
+// DeleteFile handles the deletion of a file from the storage backend.
+func (h *Handler) DeleteFile(c echo.Context) error {
+	fileId := c.Param("fileId")
+	if fileId == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "File ID is required"})
+	}
+
+	if err := h.storage.Delete(h.context(c), fileId); err != nil {
+		log.Println(err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete file"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]string{"message": "File successfully deleted", "file_id": fileId})
+}
+
+func metadataResponse(info FileInfo) map[string]interface{} {
+	return map[string]interface{}{
+		"file_id":   info.ID,
+		"file_name": info.Name,
+		"file_url":  info.WebViewLink,
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStorage stores files on the local filesystem under baseDir, grouping
+// them into folder subdirectories the same way GDriveStorage groups by
+// parent ID. A small JSON sidecar next to each file preserves content type,
+// since the filesystem itself doesn't track it.
+type LocalStorage struct {
+	baseDir string
+}
+
+// NewLocalStorage creates a Storage backed by the filesystem at baseDir,
+// creating the directory if it doesn't already exist.
+func NewLocalStorage(baseDir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(baseDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create local storage directory: %w", err)
+	}
+	return &LocalStorage{baseDir: baseDir}, nil
+}
+
+type localMeta struct {
+	ContentType string `json:"content_type"`
+}
+
+// path resolves id to a filesystem path under baseDir, rejecting any id
+// that would resolve outside of it (e.g. via ".." segments).
+func (l *LocalStorage) path(id string) (string, error) {
+	dst := filepath.Join(l.baseDir, filepath.FromSlash(id))
+
+	rel, err := filepath.Rel(l.baseDir, dst)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path %q escapes storage root", id)
+	}
+
+	return dst, nil
+}
+
+func (l *LocalStorage) metaPath(id string) (string, error) {
+	dst, err := l.path(id)
+	if err != nil {
+		return "", err
+	}
+	return dst + ".meta.json", nil
+}
+
+func (l *LocalStorage) Put(ctx context.Context, folder, name, contentType string, r io.Reader) (FileInfo, error) {
+	id := filepath.ToSlash(filepath.Join(folder, name))
+	dst, err := l.path(id)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return FileInfo{}, fmt.Errorf("failed to create local storage folder: %w", err)
+	}
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to create local file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return FileInfo{}, fmt.Errorf("failed to write local file: %w", err)
+	}
+
+	meta, err := json.Marshal(localMeta{ContentType: contentType})
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to encode local file metadata: %w", err)
+	}
+	metaDst, err := l.metaPath(id)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if err := os.WriteFile(metaDst, meta, 0o644); err != nil {
+		return FileInfo{}, fmt.Errorf("failed to write local file metadata: %w", err)
+	}
+
+	return l.Head(ctx, id)
+}
+
+func (l *LocalStorage) Get(ctx context.Context, id string) (io.ReadCloser, string, int64, error) {
+	info, err := l.Head(ctx, id)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	dst, err := l.path(id)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	f, err := os.Open(dst)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to open local file: %w", err)
+	}
+
+	return f, info.ContentType, info.Size, nil
+}
+
+func (l *LocalStorage) Head(ctx context.Context, id string) (FileInfo, error) {
+	dst, err := l.path(id)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	stat, err := os.Stat(dst)
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("file not found: %w", err)
+	}
+
+	contentType := ""
+	if metaDst, err := l.metaPath(id); err == nil {
+		if raw, err := os.ReadFile(metaDst); err == nil {
+			var meta localMeta
+			if json.Unmarshal(raw, &meta) == nil {
+				contentType = meta.ContentType
+			}
+		}
+	}
+
+	return FileInfo{
+		ID:           id,
+		Name:         filepath.Base(id),
+		Size:         stat.Size(),
+		ContentType:  contentType,
+		ModifiedTime: stat.ModTime(),
+	}, nil
+}
+
+// List ignores opts beyond folder scoping: the filesystem has no concept of
+// Shared Drives or a query DSL, and every entry is returned in one page.
+func (l *LocalStorage) List(ctx context.Context, folder string, opts ListOptions) ([]FileInfo, string, error) {
+	dir, err := l.path(folder)
+	if err != nil {
+		return nil, "", err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []FileInfo{}, "", nil
+		}
+		return nil, "", fmt.Errorf("failed to list local folder: %w", err)
+	}
+
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) == ".json" {
+			continue
+		}
+		id := filepath.ToSlash(filepath.Join(folder, entry.Name()))
+		info, err := l.Head(ctx, id)
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+	}
+	return files, "", nil
+}
+
+func (l *LocalStorage) Delete(ctx context.Context, id string) error {
+	dst, err := l.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil {
+		return fmt.Errorf("failed to delete local file: %w", err)
+	}
+	if metaDst, err := l.metaPath(id); err == nil {
+		_ = os.Remove(metaDst)
+	}
+	return nil
+}
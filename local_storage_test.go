@@ -0,0 +1,33 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalStoragePathRejectsTraversal(t *testing.T) {
+	l := &LocalStorage{baseDir: filepath.FromSlash("/srv/gdrive-api/data")}
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr bool
+	}{
+		{"plain file in root", "report.pdf", false},
+		{"nested folder", "folder1/report.pdf", false},
+		{"dotted filename is not traversal", "archive.v1.2.tar.gz", false},
+		{"parent traversal", "../secret.txt", true},
+		{"nested parent traversal", "folder1/../../secret.txt", true},
+		{"deep traversal to root", "../../../../etc/passwd", true},
+		{"traversal past root via many segments", "a/../../b", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.path(tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("path(%q) error = %v, wantErr %v (got %q)", tt.id, err, tt.wantErr, got)
+			}
+		})
+	}
+}
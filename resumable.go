@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	echo "github.com/labstack/echo/v4"
+	"google.golang.org/api/drive/v3"
+)
+
+// defaultChunkSize is the recommended chunk size for resumable uploads.
+// Google Drive requires chunk sizes to be a multiple of 256 KiB.
+const defaultChunkSize = 5 * 1024 * 1024
+
+const resumableUploadEndpoint = "https://www.googleapis.com/upload/drive/v3/files?uploadType=resumable&supportsAllDrives=true"
+
+// UploadSession tracks the state of a single resumable upload.
+type UploadSession struct {
+	SessionID     string `json:"session_id"`
+	SessionURI    string `json:"-"`
+	Name          string `json:"name"`
+	MimeType      string `json:"mime_type"`
+	ParentId      string `json:"parent_id"`
+	TotalBytes    int64  `json:"total_bytes"`
+	BytesUploaded int64  `json:"bytes_uploaded"`
+	DriveFileId   string `json:"drive_file_id,omitempty"`
+	Complete      bool   `json:"complete"`
+}
+
+// SessionStore persists resumable upload session state so it can later be
+// swapped for a durable backend (Redis/BoltDB) without touching the handlers.
+type SessionStore interface {
+	Create(session *UploadSession) error
+	Get(sessionId string) (*UploadSession, error)
+	Update(session *UploadSession) error
+}
+
+// InMemorySessionStore is the default SessionStore, backed by a guarded map.
+type InMemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*UploadSession
+}
+
+// NewInMemorySessionStore creates an empty in-memory session store.
+func NewInMemorySessionStore() *InMemorySessionStore {
+	return &InMemorySessionStore{sessions: make(map[string]*UploadSession)}
+}
+
+func (s *InMemorySessionStore) Create(session *UploadSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.SessionID] = session
+	return nil
+}
+
+func (s *InMemorySessionStore) Get(sessionId string) (*UploadSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[sessionId]
+	if !ok {
+		return nil, fmt.Errorf("upload session not found")
+	}
+	return session, nil
+}
+
+func (s *InMemorySessionStore) Update(session *UploadSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[session.SessionID]; !ok {
+		return fmt.Errorf("upload session not found")
+	}
+	s.sessions[session.SessionID] = session
+	return nil
+}
+
+// sessionStore is the process-wide store for resumable upload sessions.
+var sessionStore SessionStore = NewInMemorySessionStore()
+
+// newSessionID generates a random, URL-safe session identifier.
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// InitResumableUpload implements ResumableStorage by opening a Drive
+// resumable upload session, using the same OAuth-user-or-service-account
+// client resolution (see GDriveStorage.client) as every other endpoint.
+func (g *GDriveStorage) InitResumableUpload(ctx context.Context, name, mimeType, parentId string, size int64) (string, error) {
+	client, err := g.client(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	metadata, err := json.Marshal(&drive.File{
+		Name:    name,
+		Parents: []string{parentId},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode upload metadata: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, resumableUploadEndpoint, bytes.NewReader(metadata))
+	if err != nil {
+		return "", fmt.Errorf("failed to build upload session request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Type", mimeType)
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(size, 10))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate resumable upload session: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Google Drive rejected the resumable upload session: %s", resp.Status)
+	}
+
+	sessionURI := resp.Header.Get("Location")
+	if sessionURI == "" {
+		return "", fmt.Errorf("Google Drive did not return a session URI")
+	}
+	return sessionURI, nil
+}
+
+// InitResumableUpload starts a resumable upload session on the configured
+// storage backend and returns the session ID clients must use for
+// subsequent chunk uploads.
+func (h *Handler) InitResumableUpload(c echo.Context) error {
+	resumable, ok := h.storage.(ResumableStorage)
+	if !ok {
+		return c.JSON(http.StatusNotImplemented, map[string]string{"error": "This storage backend does not support resumable uploads"})
+	}
+
+	type requestBody struct {
+		Name     string `json:"name" form:"name"`
+		MimeType string `json:"mime_type" form:"mime_type"`
+		ParentId string `json:"parent_id" form:"parent_id"`
+		Size     int64  `json:"size" form:"size"`
+	}
+
+	var body requestBody
+	if err := c.Bind(&body); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+
+	if body.Name == "" || body.ParentId == "" || body.Size <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name, parent_id and size are required"})
+	}
+
+	sessionURI, err := resumable.InitResumableUpload(h.context(c), body.Name, body.MimeType, body.ParentId, body.Size)
+	if err != nil {
+		log.Printf("Google Drive API error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to initiate resumable upload session"})
+	}
+
+	sessionId, err := newSessionID()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to generate session ID"})
+	}
+
+	session := &UploadSession{
+		SessionID:  sessionId,
+		SessionURI: sessionURI,
+		Name:       body.Name,
+		MimeType:   body.MimeType,
+		ParentId:   body.ParentId,
+		TotalBytes: body.Size,
+	}
+	if err := sessionStore.Create(session); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to persist upload session"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Resumable upload session created",
+		"data": map[string]interface{}{
+			"session_id": sessionId,
+			"chunk_size": defaultChunkSize,
+		},
+	})
+}
+
+// parseContentRange parses a "bytes start-end/total" header into its parts.
+func parseContentRange(header string) (start, end, total int64, err error) {
+	header = strings.TrimPrefix(header, "bytes ")
+	parts := strings.SplitN(header, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, 0, fmt.Errorf("malformed Content-Range header")
+	}
+	total, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("malformed Content-Range total: %w", err)
+	}
+	rangeParts := strings.SplitN(parts[0], "-", 2)
+	if len(rangeParts) != 2 {
+		return 0, 0, 0, fmt.Errorf("malformed Content-Range byte range")
+	}
+	start, err = strconv.ParseInt(rangeParts[0], 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("malformed Content-Range start: %w", err)
+	}
+	end, err = strconv.ParseInt(rangeParts[1], 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("malformed Content-Range end: %w", err)
+	}
+	return start, end, total, nil
+}
+
+// PutResumableChunk implements ResumableStorage by forwarding a single
+// byte-range chunk to the Drive session URI returned by
+// InitResumableUpload, using the same client resolution as every other
+// endpoint.
+func (g *GDriveStorage) PutResumableChunk(ctx context.Context, sessionURI, contentRange string, body io.Reader, contentLength int64) (int, *FileInfo, error) {
+	client, err := g.client(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, sessionURI, body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to build chunk upload request: %w", err)
+	}
+	req.ContentLength = contentLength
+	req.Header.Set("Content-Range", contentRange)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to upload chunk to Google Drive: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPermanentRedirect:
+		return resp.StatusCode, nil, nil
+	case http.StatusOK, http.StatusCreated:
+		var file drive.File
+		if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+			return 0, nil, fmt.Errorf("failed to parse completed file from Google Drive: %w", err)
+		}
+		info := driveFileToInfo(&file)
+		return resp.StatusCode, &info, nil
+	default:
+		return 0, nil, fmt.Errorf("unexpected response from Google Drive: %s", resp.Status)
+	}
+}
+
+// UploadResumableChunk forwards a single byte-range chunk to the storage
+// backend and advances the session's progress.
+func (h *Handler) UploadResumableChunk(c echo.Context) error {
+	resumable, ok := h.storage.(ResumableStorage)
+	if !ok {
+		return c.JSON(http.StatusNotImplemented, map[string]string{"error": "This storage backend does not support resumable uploads"})
+	}
+
+	sessionId := c.Param("sessionId")
+	session, err := sessionStore.Get(sessionId)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Upload session not found"})
+	}
+
+	contentRange := c.Request().Header.Get("Content-Range")
+	if contentRange == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Content-Range header is required"})
+	}
+	start, end, total, err := parseContentRange(contentRange)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if total != session.TotalBytes {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Content-Range total does not match session size"})
+	}
+
+	status, info, err := resumable.PutResumableChunk(h.context(c), session.SessionURI, contentRange, c.Request().Body, end-start+1)
+	if err != nil {
+		log.Printf("Google Drive API error: %v\n", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to upload chunk to storage backend"})
+	}
+
+	switch status {
+	case http.StatusPermanentRedirect:
+		session.BytesUploaded = end + 1
+		if err := sessionStore.Update(session); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to persist upload progress"})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": "Chunk accepted",
+			"data":    sessionStatus(session),
+		})
+	case http.StatusOK, http.StatusCreated:
+		session.BytesUploaded = session.TotalBytes
+		session.DriveFileId = info.ID
+		session.Complete = true
+		if err := sessionStore.Update(session); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to persist upload completion"})
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"message": "Upload complete",
+			"data":    sessionStatus(session),
+		})
+	default:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Unexpected response from storage backend"})
+	}
+}
+
+// parseRangeHeader parses Drive's "bytes=0-12345" progress header.
+func parseRangeHeader(header string) (int64, error) {
+	header = strings.TrimPrefix(header, "bytes=")
+	parts := strings.SplitN(header, "-", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("malformed Range header")
+	}
+	return strconv.ParseInt(parts[1], 10, 64)
+}
+
+// QueryResumableStatus implements ResumableStorage by asking Drive how much
+// of sessionURI it has received so far.
+func (g *GDriveStorage) QueryResumableStatus(ctx context.Context, sessionURI string, totalBytes int64) (int, int64, *FileInfo, error) {
+	client, err := g.client(ctx)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, sessionURI, nil)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to build status request: %w", err)
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", totalBytes))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("failed to query upload status from Google Drive: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPermanentRedirect:
+		last, err := parseRangeHeader(resp.Header.Get("Range"))
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		return resp.StatusCode, last + 1, nil, nil
+	case http.StatusOK, http.StatusCreated:
+		var file drive.File
+		if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+			return 0, 0, nil, fmt.Errorf("failed to parse completed file from Google Drive: %w", err)
+		}
+		info := driveFileToInfo(&file)
+		return resp.StatusCode, totalBytes, &info, nil
+	default:
+		return resp.StatusCode, 0, nil, nil
+	}
+}
+
+// ResumableStatus reports how much of an upload session has been received,
+// querying the storage backend for its current progress on every call
+// while the session is not yet complete.
+func (h *Handler) ResumableStatus(c echo.Context) error {
+	sessionId := c.Param("sessionId")
+	session, err := sessionStore.Get(sessionId)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Upload session not found"})
+	}
+
+	if !session.Complete {
+		if resumable, ok := h.storage.(ResumableStorage); ok {
+			status, bytesUploaded, info, err := resumable.QueryResumableStatus(h.context(c), session.SessionURI, session.TotalBytes)
+			if err == nil {
+				switch status {
+				case http.StatusPermanentRedirect:
+					session.BytesUploaded = bytesUploaded
+					_ = sessionStore.Update(session)
+				case http.StatusOK, http.StatusCreated:
+					session.BytesUploaded = session.TotalBytes
+					session.DriveFileId = info.ID
+					session.Complete = true
+					_ = sessionStore.Update(session)
+				}
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"message": "Upload status retrieved",
+		"data":    sessionStatus(session),
+	})
+}
+
+func sessionStatus(session *UploadSession) map[string]interface{} {
+	percent := float64(0)
+	if session.TotalBytes > 0 {
+		percent = float64(session.BytesUploaded) / float64(session.TotalBytes) * 100
+	}
+	return map[string]interface{}{
+		"session_id":     session.SessionID,
+		"bytes_uploaded": session.BytesUploaded,
+		"total_bytes":    session.TotalBytes,
+		"percent":        percent,
+		"complete":       session.Complete,
+		"drive_file_id":  session.DriveFileId,
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		wantStart int64
+		wantEnd   int64
+		wantTotal int64
+		wantErr   bool
+	}{
+		{"valid range", "bytes 0-1048575/5242880", 0, 1048575, 5242880, false},
+		{"valid final chunk", "bytes 4194304-5242879/5242880", 4194304, 5242879, 5242880, false},
+		{"missing bytes prefix is tolerated", "0-1048575/5242880", 0, 1048575, 5242880, false},
+		{"missing total", "bytes 0-1048575", 0, 0, 0, true},
+		{"missing range dash", "bytes 0/5242880", 0, 0, 0, true},
+		{"non-numeric total", "bytes 0-1/abc", 0, 0, 0, true},
+		{"non-numeric start", "bytes a-1/5", 0, 0, 0, true},
+		{"non-numeric end", "bytes 0-b/5", 0, 0, 0, true},
+		{"empty header", "", 0, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, total, err := parseContentRange(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseContentRange(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if start != tt.wantStart || end != tt.wantEnd || total != tt.wantTotal {
+				t.Errorf("parseContentRange(%q) = (%d, %d, %d), want (%d, %d, %d)",
+					tt.header, start, end, total, tt.wantStart, tt.wantEnd, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestParseRangeHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    int64
+		wantErr bool
+	}{
+		{"valid range", "bytes=0-1048575", 1048575, false},
+		{"zero end", "bytes=0-0", 0, false},
+		{"missing bytes prefix is tolerated", "0-1048575", 1048575, false},
+		{"missing dash", "bytes=1048575", 0, true},
+		{"non-numeric end", "bytes=0-abc", 0, true},
+		{"empty header", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRangeHeader(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRangeHeader(%q) error = %v, wantErr %v", tt.header, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("parseRangeHeader(%q) = %d, want %d", tt.header, got, tt.want)
+			}
+		})
+	}
+}
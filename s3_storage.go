@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Storage stores files as objects in a single S3 bucket, using the
+// "folder/name" join as the object key, mirroring how GDriveStorage groups
+// files by parent folder ID.
+type S3Storage struct {
+	client *s3.Client
+	bucket string
+}
+
+// NewS3Storage creates a Storage backed by the given S3 bucket, loading
+// credentials and region from the default AWS SDK configuration chain.
+func NewS3Storage(ctx context.Context, bucket string) (*S3Storage, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return &S3Storage{client: s3.NewFromConfig(cfg), bucket: bucket}, nil
+}
+
+func (s *S3Storage) key(folder, name string) string {
+	return path.Join(folder, name)
+}
+
+func (s *S3Storage) Put(ctx context.Context, folder, name, contentType string, r io.Reader) (FileInfo, error) {
+	key := s.key(folder, name)
+
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        r,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to upload object to S3: %w", err)
+	}
+
+	return s.Head(ctx, key)
+}
+
+func (s *S3Storage) Get(ctx context.Context, id string) (io.ReadCloser, string, int64, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(id),
+	})
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to get object from S3: %w", err)
+	}
+
+	contentType := ""
+	if out.ContentType != nil {
+		contentType = *out.ContentType
+	}
+	size := int64(0)
+	if out.ContentLength != nil {
+		size = *out.ContentLength
+	}
+
+	return out.Body, contentType, size, nil
+}
+
+func (s *S3Storage) Head(ctx context.Context, id string) (FileInfo, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(id),
+	})
+	if err != nil {
+		return FileInfo{}, fmt.Errorf("failed to head object in S3: %w", err)
+	}
+
+	info := FileInfo{
+		ID:   id,
+		Name: path.Base(id),
+	}
+	if out.ContentLength != nil {
+		info.Size = *out.ContentLength
+	}
+	if out.ContentType != nil {
+		info.ContentType = *out.ContentType
+	}
+	if out.LastModified != nil {
+		info.ModifiedTime = *out.LastModified
+	}
+	return info, nil
+}
+
+// List honors pagination (PageSize/PageToken map onto MaxKeys/
+// ContinuationToken) but ignores the Drive-specific query DSL fields, which
+// have no S3 equivalent.
+func (s *S3Storage) List(ctx context.Context, folder string, opts ListOptions) ([]FileInfo, string, error) {
+	prefix := folder
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}
+	if opts.PageSize > 0 {
+		input.MaxKeys = aws.Int32(int32(opts.PageSize))
+	}
+	if opts.PageToken != "" {
+		input.ContinuationToken = aws.String(opts.PageToken)
+	}
+
+	out, err := s.client.ListObjectsV2(ctx, input)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list objects in S3: %w", err)
+	}
+
+	files := make([]FileInfo, 0, len(out.Contents))
+	for _, obj := range out.Contents {
+		info := FileInfo{ID: *obj.Key, Name: path.Base(*obj.Key)}
+		if obj.Size != nil {
+			info.Size = *obj.Size
+		}
+		if obj.LastModified != nil {
+			info.ModifiedTime = *obj.LastModified
+		}
+		files = append(files, info)
+	}
+
+	nextPageToken := ""
+	if out.NextContinuationToken != nil {
+		nextPageToken = *out.NextContinuationToken
+	}
+	return files, nextPageToken, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, id string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(id),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete object from S3: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// FileInfo is the backend-agnostic metadata returned for a stored file.
+type FileInfo struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type,omitempty"`
+	ModifiedTime time.Time `json:"modified_time,omitempty"`
+	WebViewLink  string    `json:"web_view_link,omitempty"`
+}
+
+// ListOptions narrows and paginates a List call. Backends that can't honor
+// a given field (anything beyond folder scoping) are free to ignore it.
+type ListOptions struct {
+	Q             string
+	MimeType      string
+	ModifiedAfter string
+	Owner         string
+	NameContains  string
+	Trashed       *bool
+	OrderBy       string
+	PageSize      int64
+	PageToken     string
+}
+
+// Storage abstracts the file operations needed by the HTTP handlers so that
+// Google Drive, local disk and S3 can all serve the same REST surface.
+type Storage interface {
+	Put(ctx context.Context, folder, name, contentType string, r io.Reader) (FileInfo, error)
+	Get(ctx context.Context, id string) (io.ReadCloser, string, int64, error)
+	Head(ctx context.Context, id string) (FileInfo, error)
+	List(ctx context.Context, folder string, opts ListOptions) (files []FileInfo, nextPageToken string, err error)
+	Delete(ctx context.Context, id string) error
+}
+
+// NameLookupStorage is implemented by backends that can resolve a file by
+// name in addition to by ID (Google Drive being the prime example).
+type NameLookupStorage interface {
+	HeadByName(ctx context.Context, name string) (FileInfo, error)
+}
+
+// GetOptions customizes a ranged/exporting Get via RangedStorage.
+type GetOptions struct {
+	// Range is the raw HTTP Range header value to forward, if any.
+	Range string
+	// Export overrides the default export format for Google Workspace
+	// files (e.g. "pdf", "docx", or a literal MIME type).
+	Export string
+}
+
+// ContentRange describes the byte range actually returned for a partial
+// download, mirroring the semantics of an HTTP Content-Range header.
+type ContentRange struct {
+	Start, End, Total int64
+}
+
+// RangedStorage is implemented by backends that can export Google Workspace
+// documents and honor byte-range requests (Google Drive being the prime
+// example). cr is non-nil only when the response is a partial (206) read.
+type RangedStorage interface {
+	GetWithOptions(ctx context.Context, id string, opts GetOptions) (r io.ReadCloser, contentType string, size int64, cr *ContentRange, err error)
+}
+
+// ThumbnailStorage is implemented by backends that can stream a file's
+// thumbnail without the caller needing backend credentials.
+type ThumbnailStorage interface {
+	Thumbnail(ctx context.Context, id, size string) (io.ReadCloser, string, error)
+}
+
+// ResumableStorage is implemented by backends that support chunked
+// resumable uploads (Google Drive being the prime example). sessionURI is
+// an opaque, backend-defined token the caller must round-trip unchanged
+// into PutResumableChunk/QueryResumableStatus.
+type ResumableStorage interface {
+	InitResumableUpload(ctx context.Context, name, mimeType, parentId string, size int64) (sessionURI string, err error)
+	PutResumableChunk(ctx context.Context, sessionURI, contentRange string, body io.Reader, contentLength int64) (statusCode int, info *FileInfo, err error)
+	QueryResumableStatus(ctx context.Context, sessionURI string, totalBytes int64) (statusCode int, bytesUploaded int64, info *FileInfo, err error)
+}
+
+// Handler wires the HTTP layer to a concrete Storage backend.
+type Handler struct {
+	storage Storage
+}
+
+// NewHandler creates a Handler backed by the given storage implementation.
+func NewHandler(storage Storage) *Handler {
+	return &Handler{storage: storage}
+}